@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// ChunkedDataCompression selects the codec ChunkedDataWriter uses to compress each
+// frame's encoded Arrow IPC bytes before sending them, independent of any transport
+// (gRPC) level compression.
+type ChunkedDataCompression string
+
+const (
+	ChunkedDataCompressionNone   ChunkedDataCompression = "none"
+	ChunkedDataCompressionZstd   ChunkedDataCompression = "zstd"
+	ChunkedDataCompressionSnappy ChunkedDataCompression = "snappy"
+	ChunkedDataCompressionLZ4    ChunkedDataCompression = "lz4"
+)
+
+// zstdEncoder is shared across writers: it's safe for concurrent use and expensive to
+// construct per-call.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// CompressChunkedDataFrame compresses encoded with the given codec. A codec of "" or
+// ChunkedDataCompressionNone returns encoded unchanged.
+func CompressChunkedDataFrame(codec ChunkedDataCompression, encoded []byte) ([]byte, error) {
+	switch codec {
+	case "", ChunkedDataCompressionNone:
+		return encoded, nil
+	case ChunkedDataCompressionZstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(encoded, make([]byte, 0, len(encoded))), nil
+	case ChunkedDataCompressionSnappy:
+		return snappy.Encode(nil, encoded), nil
+	case ChunkedDataCompressionLZ4:
+		var buf bytes.Buffer
+		w := lz4.NewWriter(&buf)
+		if _, err := w.Write(encoded); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown chunked data compression %q", codec)
+	}
+}
+
+// DecompressChunkedDataFrame reverses CompressChunkedDataFrame.
+func DecompressChunkedDataFrame(codec ChunkedDataCompression, compressed []byte) ([]byte, error) {
+	switch codec {
+	case "", ChunkedDataCompressionNone:
+		return compressed, nil
+	case ChunkedDataCompressionZstd:
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return dec.DecodeAll(compressed, nil)
+	case ChunkedDataCompressionSnappy:
+		return snappy.Decode(nil, compressed)
+	case ChunkedDataCompressionLZ4:
+		r := lz4.NewReader(bytes.NewReader(compressed))
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown chunked data compression %q", codec)
+	}
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressChunkedDataFrame_RoundTrip(t *testing.T) {
+	codecs := []ChunkedDataCompression{
+		ChunkedDataCompressionNone,
+		ChunkedDataCompressionZstd,
+		ChunkedDataCompressionSnappy,
+		ChunkedDataCompressionLZ4,
+	}
+
+	original := []byte("the quick brown fox jumps over the lazy dog, repeated for good measure: the quick brown fox jumps over the lazy dog")
+
+	for _, codec := range codecs {
+		t.Run(string(codec), func(t *testing.T) {
+			compressed, err := CompressChunkedDataFrame(codec, original)
+			require.NoError(t, err)
+
+			decompressed, err := DecompressChunkedDataFrame(codec, compressed)
+			require.NoError(t, err)
+			require.Equal(t, original, decompressed)
+		})
+	}
+}
+
+func TestCompressChunkedDataFrame_EmptyCodecIsNoCompression(t *testing.T) {
+	original := []byte("uncompressed")
+
+	compressed, err := CompressChunkedDataFrame("", original)
+	require.NoError(t, err)
+	require.Equal(t, original, compressed)
+}
+
+func TestCompressChunkedDataFrame_UnknownCodec(t *testing.T) {
+	_, err := CompressChunkedDataFrame("brotli", []byte("data"))
+	require.Error(t, err)
+
+	_, err = DecompressChunkedDataFrame("brotli", []byte("data"))
+	require.Error(t, err)
+}
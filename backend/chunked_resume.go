@@ -0,0 +1,159 @@
+package backend
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+// DefaultResumeBufferSize is how many recently flushed responses a ChunkedResumeCache
+// retains per refID when ChunkedDataOpts.ResumeBufferSize is unset.
+const DefaultResumeBufferSize = 16
+
+// chunkedResumeEntry is the last position a ChunkedDataWriter flushed for one refID of
+// one resumable query, plus a short backlog of the responses actually sent, so a client
+// that reconnects with the same ResumeToken can have them replayed instead of forcing
+// the query to restart.
+type chunkedResumeEntry struct {
+	sequence uint64
+	cursor   []byte
+	buffer   []*pluginv2.ChunkedDataResponse // oldest first, capped at the cache's buffer size
+}
+
+type chunkedResumeCacheItem struct {
+	token  string
+	refIDs map[string]*chunkedResumeEntry
+}
+
+// ChunkedResumeCache remembers, per client-supplied ResumeToken, the last Sequence and
+// Cursor a ChunkedDataWriter flushed for each refID of a resumable chunked query. On
+// reconnect with the same token, ResumeChunkedData uses it to replay whatever of that
+// backlog the client hasn't acknowledged via ChunkedDataRequest.ResumeFrom, so a dropped
+// connection doesn't force a multi-gigabyte query to restart from scratch.
+//
+// It's an LRU bounded by maxTokens entries. Share a single cache across every
+// ChunkedDataWriter created for a plugin instance via ChunkedDataOpts.ResumeCache.
+type ChunkedResumeCache struct {
+	mu        sync.Mutex
+	maxTokens int
+	ll        *list.List
+	items     map[string]*list.Element
+}
+
+// NewChunkedResumeCache creates a ChunkedResumeCache retaining state for up to maxTokens
+// distinct ResumeTokens, evicting the least recently used once full. maxTokens <= 0
+// defaults to 1024.
+func NewChunkedResumeCache(maxTokens int) *ChunkedResumeCache {
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+	return &ChunkedResumeCache{
+		maxTokens: maxTokens,
+		ll:        list.New(),
+		items:     map[string]*list.Element{},
+	}
+}
+
+// checkpoint records that resp, carrying sequence and cursor, was flushed for refID
+// under token, appending it to that refID's replay buffer and trimming it to
+// bufferSize.
+func (c *ChunkedResumeCache) checkpoint(token, refID string, sequence uint64, cursor []byte, resp *pluginv2.ChunkedDataResponse, bufferSize int) {
+	if token == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[token]
+	if !ok {
+		el = c.ll.PushFront(&chunkedResumeCacheItem{token: token, refIDs: map[string]*chunkedResumeEntry{}})
+		c.items[token] = el
+		c.evictLocked()
+	} else {
+		c.ll.MoveToFront(el)
+	}
+
+	item := el.Value.(*chunkedResumeCacheItem)
+	entry := item.refIDs[refID]
+	if entry == nil {
+		entry = &chunkedResumeEntry{}
+		item.refIDs[refID] = entry
+	}
+
+	entry.sequence = sequence
+	entry.cursor = cursor
+	entry.buffer = append(entry.buffer, resp)
+	if over := len(entry.buffer) - bufferSize; over > 0 {
+		entry.buffer = entry.buffer[over:]
+	}
+}
+
+// replayedRefID is one refID's replay result: the buffered responses the client hasn't
+// acknowledged yet, and the Sequence of the last one of them (or, if none are being
+// replayed, the last Sequence flushed for this refID before the reconnect). A resuming
+// writer seeds its own sequence counter from it so ChunkedDataResponse.Sequence keeps
+// increasing across the reconnect instead of restarting at 1.
+type replayedRefID struct {
+	responses []*pluginv2.ChunkedDataResponse
+	sequence  uint64
+}
+
+// replay returns, for each refID in resumeFrom, the buffered responses flushed after the
+// cursor the client last acknowledged, identified by matching that cursor against each
+// buffered response's Cursor — Cursor, not Sequence, is what the client actually echoes
+// back in ChunkedDataRequest.ResumeFrom. A refID is omitted from the result if token is
+// unknown, or if that refID's acknowledged cursor has aged out of the buffer (e.g. the
+// reconnect happened after more than bufferSize batches were flushed) — the caller must
+// treat an omitted refID as unresumable and let the handler produce it again from
+// scratch.
+func (c *ChunkedResumeCache) replay(token string, resumeFrom map[string][]byte) map[string]replayedRefID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[token]
+	if !ok {
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	item := el.Value.(*chunkedResumeCacheItem)
+
+	out := map[string]replayedRefID{}
+	for refID, cursor := range resumeFrom {
+		entry := item.refIDs[refID]
+		if entry == nil {
+			continue
+		}
+
+		if len(cursor) == 0 {
+			// The client never saw a checkpoint for this refID; replay everything buffered.
+			out[refID] = replayedRefID{responses: entry.buffer, sequence: entry.sequence}
+			continue
+		}
+
+		idx := -1
+		for i, resp := range entry.buffer {
+			if bytes.Equal(resp.Cursor, cursor) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+		out[refID] = replayedRefID{responses: entry.buffer[idx+1:], sequence: entry.sequence}
+	}
+	return out
+}
+
+func (c *ChunkedResumeCache) evictLocked() {
+	for c.ll.Len() > c.maxTokens {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*chunkedResumeCacheItem).token)
+	}
+}
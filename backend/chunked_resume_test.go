@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+func TestChunkedResumeCache_ReplayAndCheckpoint(t *testing.T) {
+	c := NewChunkedResumeCache(0)
+
+	resp1 := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 1, Cursor: []byte("c1")}
+	resp2 := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 2, Cursor: []byte("c2")}
+	resp3 := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 3, Cursor: []byte("c3")}
+
+	c.checkpoint("tok", "A", 1, []byte("c1"), resp1, 16)
+	c.checkpoint("tok", "A", 2, []byte("c2"), resp2, 16)
+	c.checkpoint("tok", "A", 3, []byte("c3"), resp3, 16)
+
+	replayed := c.replay("tok", map[string][]byte{"A": []byte("c1")})
+	require.Contains(t, replayed, "A")
+	require.Equal(t, []*pluginv2.ChunkedDataResponse{resp2, resp3}, replayed["A"].responses)
+	require.Equal(t, uint64(3), replayed["A"].sequence)
+}
+
+func TestChunkedResumeCache_ReplayUnknownCursorOrToken(t *testing.T) {
+	c := NewChunkedResumeCache(0)
+
+	resp := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 1, Cursor: []byte("c1")}
+	c.checkpoint("tok", "A", 1, []byte("c1"), resp, 16)
+
+	// Unknown token.
+	require.Nil(t, c.replay("other-tok", map[string][]byte{"A": []byte("c1")}))
+
+	// Known token, but the cursor never appeared in the buffer (aged out or bogus).
+	replayed := c.replay("tok", map[string][]byte{"A": []byte("stale")})
+	require.NotContains(t, replayed, "A")
+}
+
+func TestChunkedResumeCache_ReplayEmptyCursorReplaysEverything(t *testing.T) {
+	c := NewChunkedResumeCache(0)
+
+	resp1 := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 1, Cursor: []byte("c1")}
+	resp2 := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 2, Cursor: []byte("c2")}
+	c.checkpoint("tok", "A", 1, []byte("c1"), resp1, 16)
+	c.checkpoint("tok", "A", 2, []byte("c2"), resp2, 16)
+
+	replayed := c.replay("tok", map[string][]byte{"A": nil})
+	require.Equal(t, []*pluginv2.ChunkedDataResponse{resp1, resp2}, replayed["A"].responses)
+}
+
+func TestChunkedResumeCache_BufferSizeTrimsOldestEntries(t *testing.T) {
+	c := NewChunkedResumeCache(0)
+
+	for i := uint64(1); i <= 5; i++ {
+		resp := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: i, Cursor: []byte{byte(i)}}
+		c.checkpoint("tok", "A", i, []byte{byte(i)}, resp, 2)
+	}
+
+	// Only the last 2 responses (sequence 4 and 5) should still be buffered, so
+	// resuming from the cursor for sequence 2 (aged out) can't be satisfied.
+	replayed := c.replay("tok", map[string][]byte{"A": {2}})
+	require.NotContains(t, replayed, "A")
+
+	replayed = c.replay("tok", map[string][]byte{"A": {4}})
+	require.Len(t, replayed["A"].responses, 1)
+	require.Equal(t, uint64(5), replayed["A"].responses[0].Sequence)
+}
+
+func TestChunkedResumeCache_EvictsLeastRecentlyUsedToken(t *testing.T) {
+	c := NewChunkedResumeCache(2)
+
+	resp := &pluginv2.ChunkedDataResponse{RefId: "A", Sequence: 1, Cursor: []byte("c1")}
+	c.checkpoint("tok1", "A", 1, []byte("c1"), resp, 16)
+	c.checkpoint("tok2", "A", 1, []byte("c1"), resp, 16)
+
+	// Touch tok1 so tok2 becomes the least recently used.
+	c.replay("tok1", map[string][]byte{"A": nil})
+
+	// Adding a third token evicts tok2, the LRU entry.
+	c.checkpoint("tok3", "A", 1, []byte("c1"), resp, 16)
+
+	require.NotNil(t, c.replay("tok1", map[string][]byte{"A": nil}))
+	require.Nil(t, c.replay("tok2", map[string][]byte{"A": nil}))
+	require.NotNil(t, c.replay("tok3", map[string][]byte{"A": nil}))
+}
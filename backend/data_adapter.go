@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
@@ -17,66 +21,251 @@ import (
 
 const (
 	errorSourceMetadataKey = "errorSource"
+	panicMetadataKey       = "panic"
+
+	// maxPanicStackFrames bounds the stack trace captured for a recovered panic, the
+	// same way Terraform's plugin panic recorder does.
+	maxPanicStackFrames = 100
 )
 
 // dataSDKAdapter adapter between low level plugin protocol and SDK interfaces.
 type dataSDKAdapter struct {
 	queryDataHandler   QueryDataHandler
 	chunkedDataHandler ChunkedDataHandler
+
+	// disableRecovery disables the panic recovery around queryDataHandler and
+	// chunkedDataHandler, so a handler panic crashes the plugin process instead of
+	// being turned into a codes.Internal error. Set via ManageOpts.DisableRecovery.
+	disableRecovery bool
+
+	// chunkedDataOpts configures batching, compression and resumption for every
+	// chunkedDataWriter this adapter creates. Set via ManageOpts.ChunkedDataOpts.
+	chunkedDataOpts ChunkedDataOpts
 }
 
-// newDataSDKAdapter creates a new adapter between the plugin protocol and SDK interfaces.
-// It handles both query data and stream data operations.
-func newDataSDKAdapter(queryDataHandler QueryDataHandler, chunkedDataHandler ChunkedDataHandler) *dataSDKAdapter {
+// NewDataAdapter creates a new adapter between the plugin protocol and SDK interfaces,
+// handling both QueryData and QueryChunkedData. It's exported so a plugin host that
+// doesn't go through Serve — such as experimental/datasourcetest.Manage — can still get
+// the same error-source enrichment and panic recovery a production plugin gets, instead
+// of reimplementing that glue against the raw QueryDataHandler/ChunkedDataHandler.
+func NewDataAdapter(queryDataHandler QueryDataHandler, chunkedDataHandler ChunkedDataHandler, disableRecovery bool, chunkedDataOpts ChunkedDataOpts) *dataSDKAdapter {
 	return &dataSDKAdapter{
 		queryDataHandler:   queryDataHandler,
 		chunkedDataHandler: chunkedDataHandler,
+		disableRecovery:    disableRecovery,
+		chunkedDataOpts:    chunkedDataOpts,
 	}
 }
 
 // QueryData handles incoming gRPC data requests by converting them to SDK format
 // and passing them to the registered QueryDataHandler.
-func (a *dataSDKAdapter) QueryData(ctx context.Context, req *pluginv2.QueryDataRequest) (*pluginv2.QueryDataResponse, error) {
+func (a *dataSDKAdapter) QueryData(ctx context.Context, req *pluginv2.QueryDataRequest) (resp *pluginv2.QueryDataResponse, err error) {
+	if !a.disableRecovery {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, a.recoverQueryData(req, r)
+			}
+		}()
+	}
+
 	parsedReq := FromProto().QueryDataRequest(req)
-	resp, err := a.queryDataHandler.QueryData(ctx, parsedReq)
+	qdr, err := a.queryDataHandler.QueryData(ctx, parsedReq)
 	if err != nil {
 		return nil, enrichWithErrorSourceInfo(err)
 	}
 
-	if resp == nil {
+	if qdr == nil {
 		return nil, errors.New("both response and error are nil, but one must be provided")
 	}
 
-	return ToProto().QueryDataResponse(resp)
+	return ToProto().QueryDataResponse(qdr)
+}
+
+// recoverQueryData logs a panic recovered from the QueryDataHandler, tagged with the
+// RefIDs of the queries being served, and turns it into a codes.Internal gRPC status
+// the host can recognize as a plugin panic via ErrorSourceFromGrpcStatusError.
+func (a *dataSDKAdapter) recoverQueryData(req *pluginv2.QueryDataRequest, r any) error {
+	refIDs := make([]string, 0, len(req.Queries))
+	for _, q := range req.Queries {
+		refIDs = append(refIDs, q.RefId)
+	}
+
+	stack := panicStack()
+	Logger.Error("plugin panic in QueryData", "panic", r, "refIDs", refIDs, "stack", stack)
+
+	return PanicToGrpcError(r)
 }
 
 // QueryChunkedData handles incoming gRPC stream data requests by converting them to SDK format
 // and passing them to the registered ChunkedDataHandler.
-func (a *dataSDKAdapter) QueryChunkedData(req *pluginv2.ChunkedDataRequest, stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]) error {
+func (a *dataSDKAdapter) QueryChunkedData(req *pluginv2.ChunkedDataRequest, stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]) (err error) {
 	ctx := stream.Context()
 	parsedReq := FromProto().ChunkedDataRequest(req)
-	writer := newChunkedDataWriter(stream)
+	writer := newChunkedDataWriterWithOpts(stream, a.chunkedDataOpts)
 
-	err := a.chunkedDataHandler.QueryChunkedData(ctx, parsedReq, writer)
-	if err != nil {
+	if !a.disableRecovery {
+		defer func() {
+			if r := recover(); r != nil {
+				err = a.recoverQueryChunkedData(writer, r)
+			}
+		}()
+	}
+
+	if req.ResumeToken != "" {
+		if err := writer.resume(req.ResumeToken, req.ResumeFrom); err != nil {
+			return err
+		}
+	}
+
+	if err := a.chunkedDataHandler.QueryChunkedData(ctx, parsedReq, writer); err != nil {
 		return enrichWithErrorSourceInfo(err)
 	}
 
 	return nil
 }
 
+// recoverQueryChunkedData logs a panic recovered from the ChunkedDataHandler. If the
+// writer already flushed frames for some refIDs before the panic, it first sends a
+// final response carrying the panic as an error for every refID still open, so the
+// client doesn't mistake a truncated stream for a complete one.
+func (a *dataSDKAdapter) recoverQueryChunkedData(writer *chunkedDataWriter, r any) error {
+	stack := panicStack()
+	Logger.Error("plugin panic in QueryChunkedData", "panic", r, "stack", stack)
+
+	panicErr := fmt.Errorf("panic: %v", r)
+	for _, refID := range writer.refIDs() {
+		_ = writer.WriteError(refID, panicErr)
+	}
+
+	return PanicToGrpcError(r)
+}
+
+// panicStack captures the first maxPanicStackFrames lines of the current goroutine's
+// stack; each frame takes two lines, so that's about half as many frames.
+func panicStack() string {
+	buf := make([]byte, 1<<16)
+	n := runtime.Stack(buf, false)
+	lines := strings.SplitN(string(buf[:n]), "\n", maxPanicStackFrames+1)
+	if len(lines) > maxPanicStackFrames {
+		lines = append(lines[:maxPanicStackFrames], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PanicToGrpcError converts a recovered panic value into a codes.Internal gRPC status
+// error tagged as a plugin panic, so hosts can distinguish it from an ordinary error via
+// ErrorSourceFromGrpcStatusError. It's exported for backend/grpcmw's recovery
+// interceptors, which recover panics from handlers other than QueryData/QueryChunkedData
+// but need the same gRPC status shape.
+func PanicToGrpcError(r any) error {
+	st := grpcstatus.New(codes.Internal, fmt.Sprintf("panic: %v", r))
+
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Metadata: map[string]string{
+			errorSourceMetadataKey: string(ErrorSourcePlugin),
+			panicMetadataKey:       "true",
+		},
+	})
+	if detailsErr != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// ChunkedDataOpts configures how a ChunkedDataWriter batches and compresses frames
+// before sending them.
+type ChunkedDataOpts struct {
+	// MaxBatchRows flushes once this many rows have been buffered across all refIDs.
+	// Defaults to 1000.
+	MaxBatchRows int
+
+	// MaxBatchBytes flushes once the cumulative encoded size of buffered, completed
+	// frames reaches this many bytes. Zero disables the byte-based trigger.
+	MaxBatchBytes int
+
+	// MaxBatchLatency flushes this long after the first buffered write, even if
+	// neither MaxBatchRows nor MaxBatchBytes has been reached. Zero disables the
+	// latency-based trigger.
+	MaxBatchLatency time.Duration
+
+	// Compression selects the codec applied to each frame's encoded bytes. Defaults
+	// to ChunkedDataCompressionNone.
+	Compression ChunkedDataCompression
+
+	// SendQueueDepth bounds how many flushed responses may be queued for the stream's
+	// dedicated sender goroutine before WriteFrame/WriteFrameRow block, applying
+	// backpressure to a handler that's producing faster than the client can consume.
+	// Defaults to 4.
+	SendQueueDepth int
+
+	// ResumeCache, if set, lets a client resume a chunked query across a reconnect
+	// instead of restarting it: the writer records every flushed response under the
+	// request's ResumeToken, and ResumeChunkedData replays whatever of that backlog the
+	// client hasn't acknowledged via ChunkedDataRequest.ResumeFrom. Nil disables
+	// resumption; a request's ResumeToken is then ignored.
+	ResumeCache *ChunkedResumeCache
+
+	// ResumeBufferSize caps how many recently flushed responses ResumeCache retains per
+	// refID. Defaults to DefaultResumeBufferSize. Has no effect if ResumeCache is nil.
+	ResumeBufferSize int
+}
+
+func (o ChunkedDataOpts) withDefaults() ChunkedDataOpts {
+	if o.MaxBatchRows <= 0 {
+		o.MaxBatchRows = 1000 // matches the writer's previous, non-configurable default
+	}
+	if o.SendQueueDepth <= 0 {
+		o.SendQueueDepth = 4
+	}
+	if o.ResumeBufferSize <= 0 {
+		o.ResumeBufferSize = DefaultResumeBufferSize
+	}
+	return o
+}
+
 // chunkedDataWriter implements the ChunkedDataWriter interface for gRPC streaming.
 // It buffers data frames and manages efficient transmission to clients.
 type chunkedDataWriter struct {
 	stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]
+	opts   ChunkedDataOpts
 	states map[string]*chunkingState
 	count  int
+	bytes  int
+
+	// resumeToken is the ChunkedDataRequest.ResumeToken the current stream was asked to
+	// resume under, if any. It's set by resume and used to key opts.ResumeCache when
+	// flushLocked checkpoints a response.
+	resumeToken string
+	sequences   map[string]uint64 // per-refID monotonically increasing ChunkedDataResponse.Sequence
+
+	mu         sync.Mutex
+	timer      *time.Timer
+	timerArmed bool
+
+	sendOnce   sync.Once
+	sendCh     chan *pluginv2.ChunkedDataResponse
+	sendDone   chan struct{}
+	sendBroken chan struct{} // closed once the sender goroutine gives up on a Send error
+
+	// sendErrMu guards sendErr independently of mu: the sender goroutine must be able
+	// to record a Send failure without waiting on mu, which a producer blocked in
+	// enqueueSend (backpressure) may be holding.
+	sendErrMu sync.Mutex
+	sendErr   error
 }
 
 // chunkingState maintains the chunking state of data frames for a specific refID.
 type chunkingState struct {
-	frames   []*data.Frame
-	curFrame *data.Frame // Pointer to the most recently added frame
+	frames         []*data.Frame
+	curFrame       *data.Frame // Pointer to the most recently added frame
+	committedBytes int         // encoded size of every frame in frames except curFrame
+
+	// pendingCursor is the opaque resume position the handler last passed to
+	// Checkpoint for this refID. It's attached to the next flushed ChunkedDataResponse
+	// and cleared by reset, so it only ever describes data in the batch about to be
+	// sent, never a stale one.
+	pendingCursor []byte
 
 	// Error handling fields
 	Error       error
@@ -88,9 +277,23 @@ type chunkingState struct {
 // It helps both the sender and receiver manage frame boundaries during data streaming.
 var markerFrame = data.NewFrame("")
 
-func (st *chunkingState) addFrame(f *data.Frame) {
+// addFrame finalizes the previous curFrame's encoded size into committedBytes, so
+// cumulative byte-based flushing doesn't need to re-marshal frames that are done being
+// appended to, then starts tracking f as the new curFrame. It returns the additional
+// committed bytes, if any, for the caller to fold into the writer's running total.
+func (st *chunkingState) addFrame(f *data.Frame) (addedBytes int, err error) {
+	if st.curFrame != nil {
+		encoded, err := st.curFrame.MarshalArrow()
+		if err != nil {
+			return 0, err
+		}
+		st.committedBytes += len(encoded)
+		addedBytes = len(encoded)
+	}
+
 	st.frames = append(st.frames, markerFrame, f)
 	st.curFrame = f
+	return addedBytes, nil
 }
 
 func (st *chunkingState) addRow(fields ...any) error {
@@ -122,76 +325,301 @@ func (st *chunkingState) reset() {
 	}
 }
 
-// newChunkedDataWriter creates a new writer that handles sending chunked data over gRPC.
-// It manages buffering and efficient transmission of frames to clients.
-func newChunkedDataWriter(stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]) *chunkedDataWriter {
+// newChunkedDataWriterWithOpts creates a new writer that flushes buffered frames once
+// row count, cumulative encoded bytes, or time since the first buffered write crosses
+// the configured thresholds, compressing each frame's encoded bytes with opts.Compression.
+func newChunkedDataWriterWithOpts(stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse], opts ChunkedDataOpts) *chunkedDataWriter {
 	return &chunkedDataWriter{
-		stream: stream,
-		states: map[string]*chunkingState{},
+		stream:    stream,
+		opts:      opts.withDefaults(),
+		states:    map[string]*chunkingState{},
+		sequences: map[string]uint64{},
 	}
 }
 
+// NewChunkedDataWriter creates a ChunkedDataWriter that streams frames over stream. It's
+// exported for use by harnesses, such as experimental/datasourcetest, that host a
+// plugin's gRPC server without going through Serve.
+func NewChunkedDataWriter(stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse], opts ChunkedDataOpts) ChunkedDataWriter {
+	return newChunkedDataWriterWithOpts(stream, opts)
+}
+
+// ResumeChunkedData replays, onto writer, whatever of token's resume buffer the client
+// hasn't acknowledged via resumeFrom (refID -> last cursor the client saw), removing
+// from resumeFrom any refID it successfully replayed. Call it once, before invoking the
+// ChunkedDataHandler, with the writer returned by NewChunkedDataWriter and a request's
+// ResumeToken/ResumeFrom, so a client that reconnects resumes instead of restarting. A
+// refID left in resumeFrom after this call couldn't be resumed and must be produced
+// again from the start. It's a no-op if writer's ChunkedDataOpts.ResumeCache is nil.
+func ResumeChunkedData(writer ChunkedDataWriter, token string, resumeFrom map[string][]byte) error {
+	w, ok := writer.(*chunkedDataWriter)
+	if !ok {
+		return nil
+	}
+	return w.resume(token, resumeFrom)
+}
+
 func (w *chunkedDataWriter) WriteFrame(refID string, f *data.Frame) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.stream.Context().Err(); err != nil {
+		return err
+	}
+
 	state := w.states[refID]
 	if state == nil {
 		state = &chunkingState{}
 		w.states[refID] = state
 	}
 	f.RefID = refID
-	state.addFrame(f)
+	addedBytes, err := state.addFrame(f)
+	if err != nil {
+		return err
+	}
 
 	w.count += f.Rows()
-	return w.maybeFlush()
+	w.bytes += addedBytes
+	w.armLatencyTimer()
+	return w.maybeFlushLocked()
 }
 
 func (w *chunkedDataWriter) WriteFrameRow(refID string, fields ...any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.stream.Context().Err(); err != nil {
+		return err
+	}
+
 	state := w.states[refID]
 	if err := state.addRow(fields...); err != nil {
 		return err
 	}
 
 	w.count++
-	return w.maybeFlush()
+	w.armLatencyTimer()
+	return w.maybeFlushLocked()
+}
+
+// Checkpoint marks cursor as the resume position for refID's next flush. A handler
+// calls it after committing a logical batch of rows (e.g. an upstream page or time
+// bucket), not after every WriteFrameRow; cursor is opaque to the SDK and is only ever
+// handed back to the handler via the next request's ChunkedDataRequest.ResumeFrom. It's
+// attached to the ChunkedDataResponse sent by the next flush and, if ResumeCache is
+// configured, recorded under the stream's ResumeToken so ResumeChunkedData can replay
+// it to a client that reconnects with the same token.
+func (w *chunkedDataWriter) Checkpoint(refID string, cursor []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state := w.states[refID]
+	if state == nil {
+		return fmt.Errorf("no frame being processed for refID %q, cannot checkpoint", refID)
+	}
+	state.pendingCursor = cursor
+	return nil
+}
+
+// resume replays, onto the stream, whatever of token's resume buffer the client hasn't
+// acknowledged via resumeFrom (refID -> last cursor the client saw), removing from
+// resumeFrom any refID it successfully replayed. A refID left in resumeFrom couldn't be
+// resumed — either token is unknown or that refID's cursor aged out of the buffer — and
+// the handler must produce it again from the start.
+func (w *chunkedDataWriter) resume(token string, resumeFrom map[string][]byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.resumeToken = token
+	if token == "" || w.opts.ResumeCache == nil || len(resumeFrom) == 0 {
+		return nil
+	}
+
+	replayed := w.opts.ResumeCache.replay(token, resumeFrom)
+	if len(replayed) == 0 {
+		return nil
+	}
+
+	w.startSender()
+	for refID, r := range replayed {
+		for _, resp := range r.responses {
+			if err := w.enqueueSend(resp); err != nil {
+				return err
+			}
+		}
+		// Seed this refID's counter from what the client already saw, so the next
+		// flush's Sequence keeps increasing across the reconnect instead of restarting
+		// at 1.
+		w.sequences[refID] = r.sequence
+		delete(resumeFrom, refID)
+	}
+	return nil
+}
+
+// refIDs returns a snapshot of the refIDs currently known to the writer, safe to range
+// over without holding w.mu (unlike ranging w.states directly, which a concurrent
+// WriteFrame/WriteFrameRow/WriteError for a different refID would race).
+func (w *chunkedDataWriter) refIDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	refIDs := make([]string, 0, len(w.states))
+	for refID := range w.states {
+		refIDs = append(refIDs, refID)
+	}
+	return refIDs
 }
 
 func (w *chunkedDataWriter) WriteError(refID string, err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	state := w.states[refID]
 	state.Error = err
 	w.states[refID] = state
 
 	w.count++
-	return w.flush()
+	return w.flushLocked()
 }
 
 func (w *chunkedDataWriter) Close() error {
-	return w.flush()
+	w.mu.Lock()
+	w.stopLatencyTimer()
+	flushErr := w.flushLocked()
+	sendCh := w.sendCh
+	w.mu.Unlock()
+
+	if sendCh != nil {
+		close(sendCh)
+		<-w.sendDone
+	}
+
+	if flushErr != nil {
+		return flushErr
+	}
+
+	w.sendErrMu.Lock()
+	defer w.sendErrMu.Unlock()
+	return w.sendErr
+}
+
+// startSender lazily starts the goroutine that owns stream.Send, so a producer calling
+// WriteFrame/WriteFrameRow can keep building the next batch while the previous one is
+// still on the wire. It must be called with w.mu held.
+func (w *chunkedDataWriter) startSender() {
+	w.sendOnce.Do(func() {
+		w.sendCh = make(chan *pluginv2.ChunkedDataResponse, w.opts.SendQueueDepth)
+		w.sendDone = make(chan struct{})
+		w.sendBroken = make(chan struct{})
+		go w.sendLoop()
+	})
+}
+
+func (w *chunkedDataWriter) sendLoop() {
+	defer close(w.sendDone)
+
+	for resp := range w.sendCh {
+		if err := w.stream.Send(resp); err != nil {
+			w.sendErrMu.Lock()
+			if w.sendErr == nil {
+				w.sendErr = err
+			}
+			w.sendErrMu.Unlock()
+			close(w.sendBroken)
+
+			// The stream is dead; drain the rest without sending so a producer
+			// blocked on a full channel isn't stuck forever.
+			for range w.sendCh {
+			}
+			return
+		}
+	}
+}
+
+// enqueueSend hands resp to the sender goroutine, blocking (applying backpressure to
+// the caller) if SendQueueDepth responses are already queued, and unblocking early if
+// the client disconnects or cancels. It must be called with w.mu held; startSender must
+// have been called first.
+func (w *chunkedDataWriter) enqueueSend(resp *pluginv2.ChunkedDataResponse) error {
+	select {
+	case w.sendCh <- resp:
+		return nil
+	case <-w.sendBroken:
+		w.sendErrMu.Lock()
+		defer w.sendErrMu.Unlock()
+		return w.sendErr
+	case <-w.stream.Context().Done():
+		return w.stream.Context().Err()
+	}
+}
+
+// armLatencyTimer starts (or, via time.Timer, effectively restarts from the first
+// buffered write) the MaxBatchLatency countdown. It's only armed once per batch, on the
+// first write after a flush, since the goal is "don't sit on buffered data longer than
+// this", not "flush if idle for this long".
+func (w *chunkedDataWriter) armLatencyTimer() {
+	if w.opts.MaxBatchLatency <= 0 || w.timerArmed {
+		return
+	}
+	w.timerArmed = true
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.opts.MaxBatchLatency, w.flushOnTimer)
+		return
+	}
+	w.timer.Reset(w.opts.MaxBatchLatency)
+}
+
+func (w *chunkedDataWriter) stopLatencyTimer() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timerArmed = false
 }
 
-func (w *chunkedDataWriter) maybeFlush() error {
-	const maxBatchSize = 1000 // can be tuned
-	if w.count < maxBatchSize {
+func (w *chunkedDataWriter) flushOnTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timerArmed = false
+	_ = w.flushLocked()
+}
+
+func (w *chunkedDataWriter) maybeFlushLocked() error {
+	if w.count < w.opts.MaxBatchRows && (w.opts.MaxBatchBytes <= 0 || w.bytes < w.opts.MaxBatchBytes) {
 		return nil
 	}
-	return w.flush()
+	return w.flushLocked()
 }
 
-func (w *chunkedDataWriter) flush() error {
+func (w *chunkedDataWriter) flushLocked() error {
 	if w.count == 0 {
 		return nil
 	}
 
+	if err := w.stream.Context().Err(); err != nil {
+		return err
+	}
+	w.startSender()
+
 	for refID, state := range w.states {
 		errStr := ""
 		if state.Error != nil {
 			errStr = state.Error.Error()
 		}
 
+		w.sequences[refID]++
+		seq := w.sequences[refID]
+
 		resp := &pluginv2.ChunkedDataResponse{
 			RefId:       refID,
 			Frames:      make([][]byte, 0, len(state.frames)),
 			Status:      int32(state.Status),
 			Error:       errStr,
 			ErrorSource: state.ErrorSource.String(),
+			Compression: string(w.opts.Compression),
+			Sequence:    seq,
+			Cursor:      state.pendingCursor,
 		}
 
 		for _, frame := range state.frames {
@@ -199,12 +627,22 @@ func (w *chunkedDataWriter) flush() error {
 			if err != nil {
 				return err
 			}
+
+			encoded, err = CompressChunkedDataFrame(w.opts.Compression, encoded)
+			if err != nil {
+				return err
+			}
+
 			resp.Frames = append(resp.Frames, encoded)
 		}
 
-		if err := w.stream.Send(resp); err != nil {
+		if err := w.enqueueSend(resp); err != nil {
 			return err
 		}
+
+		if w.opts.ResumeCache != nil && w.resumeToken != "" {
+			w.opts.ResumeCache.checkpoint(w.resumeToken, refID, seq, state.pendingCursor, resp, w.opts.ResumeBufferSize)
+		}
 	}
 
 	// Reset state
@@ -212,6 +650,8 @@ func (w *chunkedDataWriter) flush() error {
 		state.reset()
 	}
 	w.count = 0
+	w.bytes = 0
+	w.timerArmed = false
 
 	return nil
 }
@@ -243,6 +683,33 @@ func enrichWithErrorSourceInfo(err error) error {
 	return status.Err()
 }
 
+// ErrorSourceFromGrpcStatus extracts the error source from a gRPC status error's
+// details, without touching ctx. Use it to inspect an error's source for logging or
+// metrics; use ErrorSourceFromGrpcStatusError instead when the source also needs to be
+// injected into ctx for downstream host-side processing.
+func ErrorSourceFromGrpcStatus(err error) (status.Source, bool) {
+	st := grpcstatus.Convert(err)
+	if st == nil {
+		return status.DefaultSource, false
+	}
+	for _, detail := range st.Details() {
+		if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+			errorSource, exists := errorInfo.Metadata[errorSourceMetadataKey]
+			if !exists {
+				break
+			}
+
+			switch errorSource {
+			case string(ErrorSourceDownstream):
+				return status.SourceDownstream, true
+			case string(ErrorSourcePlugin):
+				return status.SourcePlugin, true
+			}
+		}
+	}
+	return status.DefaultSource, false
+}
+
 // HandleGrpcStatusError handles gRPC status errors by extracting the error source from the error details and injecting
 // the error source into context.
 func ErrorSourceFromGrpcStatusError(ctx context.Context, err error) (status.Source, bool) {
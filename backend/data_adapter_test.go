@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+// fakeChunkedDataStream is a minimal grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]
+// whose Send can be made to block or fail, so tests can drive chunkedDataWriter's
+// backpressure and error paths without a real gRPC connection.
+type fakeChunkedDataStream struct {
+	ctx      context.Context
+	sendFunc func(*pluginv2.ChunkedDataResponse) error
+}
+
+func (f *fakeChunkedDataStream) Send(resp *pluginv2.ChunkedDataResponse) error {
+	if f.sendFunc != nil {
+		return f.sendFunc(resp)
+	}
+	return nil
+}
+
+func (f *fakeChunkedDataStream) Context() context.Context    { return f.ctx }
+func (f *fakeChunkedDataStream) SetHeader(metadata.MD) error  { return nil }
+func (f *fakeChunkedDataStream) SendHeader(metadata.MD) error { return nil }
+func (f *fakeChunkedDataStream) SetTrailer(metadata.MD)       {}
+func (f *fakeChunkedDataStream) SendMsg(m any) error          { return nil }
+func (f *fakeChunkedDataStream) RecvMsg(m any) error          { return nil }
+
+func newTestFrame(refID string) *data.Frame {
+	f := data.NewFrame("", data.NewField("v", nil, []int64{}))
+	f.AppendRow(int64(1))
+	f.RefID = refID
+	return f
+}
+
+func TestChunkedDataWriter_EnqueueSendBlocksOnFullQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockSend := make(chan struct{})
+	stream := &fakeChunkedDataStream{
+		ctx: ctx,
+		sendFunc: func(*pluginv2.ChunkedDataResponse) error {
+			<-blockSend
+			return nil
+		},
+	}
+
+	w := newChunkedDataWriterWithOpts(stream, ChunkedDataOpts{
+		MaxBatchRows:   1,
+		SendQueueDepth: 1,
+	})
+
+	// The first flush starts the sender goroutine, which immediately blocks in Send.
+	require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+	// The second flush fills the SendQueueDepth-1 buffered channel; it may block
+	// briefly until the sender goroutine dequeues the first response, but returns.
+	require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+
+	// A third flush has nowhere to go: the sender is blocked in Send and the queue is
+	// full, so WriteFrame must block applying backpressure until something unblocks it.
+	done := make(chan error, 1)
+	go func() { done <- w.WriteFrame("A", newTestFrame("A")) }()
+
+	select {
+	case <-done:
+		t.Fatal("WriteFrame returned without the queue draining; backpressure not applied")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(blockSend)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WriteFrame never unblocked after Send drained the queue")
+	}
+}
+
+func TestChunkedDataWriter_EnqueueSendUnblocksOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	blockSend := make(chan struct{})
+	stream := &fakeChunkedDataStream{
+		ctx: ctx,
+		sendFunc: func(*pluginv2.ChunkedDataResponse) error {
+			<-blockSend
+			return nil
+		},
+	}
+
+	w := newChunkedDataWriterWithOpts(stream, ChunkedDataOpts{
+		MaxBatchRows:   1,
+		SendQueueDepth: 1,
+	})
+
+	require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+	require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+
+	done := make(chan error, 1)
+	go func() { done <- w.WriteFrame("A", newTestFrame("A")) }()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("WriteFrame did not unblock on context cancellation")
+	}
+	close(blockSend)
+}
+
+func TestChunkedDataWriter_SendErrorBreaksSenderAndDrainsQueue(t *testing.T) {
+	ctx := context.Background()
+	sendErr := errors.New("send failed")
+
+	var callCount int
+	stream := &fakeChunkedDataStream{
+		ctx: ctx,
+		sendFunc: func(*pluginv2.ChunkedDataResponse) error {
+			callCount++
+			return sendErr
+		},
+	}
+
+	w := newChunkedDataWriterWithOpts(stream, ChunkedDataOpts{
+		MaxBatchRows:   1,
+		SendQueueDepth: 4,
+	})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+	}
+
+	err := w.Close()
+	require.ErrorIs(t, err, sendErr)
+}
+
+func TestChunkedDataWriter_RecoverQueryChunkedDataSnapshotsRefIDs(t *testing.T) {
+	ctx := context.Background()
+	stream := &fakeChunkedDataStream{ctx: ctx}
+
+	w := newChunkedDataWriterWithOpts(stream, ChunkedDataOpts{MaxBatchRows: 1000})
+	require.NoError(t, w.WriteFrame("A", newTestFrame("A")))
+	require.NoError(t, w.WriteFrame("B", newTestFrame("B")))
+
+	a := &dataSDKAdapter{}
+	err := a.recoverQueryChunkedData(w, "boom")
+	require.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package grpcmw
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+// ContextTagsUnaryInterceptor stamps the request's PluginContext onto the contextual
+// log attributes carried in ctx, so every log line emitted further down the handler
+// chain is tagged with plugin/datasource/org identifiers without the handler having to
+// do it itself.
+func ContextTagsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withPluginContextTags(ctx, pluginContextFromRequest(req)), req)
+	}
+}
+
+// QueryChunkedData's PluginContext is only decoded by the handler itself (a
+// grpc.ServerStream doesn't expose the message body to an interceptor), so there's no
+// streaming counterpart to ContextTagsUnaryInterceptor — tag the writer's logger
+// directly from ChunkedDataHandler.QueryChunkedData instead.
+
+// pluginContextFromRequest extracts the embedded PluginContext from any of the plugin
+// protocol's typed requests.
+func pluginContextFromRequest(req any) *pluginv2.PluginContext {
+	switch r := req.(type) {
+	case *pluginv2.QueryDataRequest:
+		return r.PluginContext
+	case *pluginv2.ChunkedDataRequest:
+		return r.PluginContext
+	case *pluginv2.CheckHealthRequest:
+		return r.PluginContext
+	case *pluginv2.CallResourceRequest:
+		return r.PluginContext
+	default:
+		return nil
+	}
+}
+
+func withPluginContextTags(ctx context.Context, protoPCtx *pluginv2.PluginContext) context.Context {
+	if protoPCtx == nil {
+		return ctx
+	}
+
+	pCtx := backend.FromProto().PluginContext(protoPCtx)
+
+	args := []any{"pluginID", pCtx.PluginID, "orgID", pCtx.OrgID}
+	if pCtx.DataSourceInstanceSettings != nil {
+		args = append(args, "datasourceUID", pCtx.DataSourceInstanceSettings.UID)
+	}
+
+	return log.WithContextualAttributes(ctx, args)
+}
@@ -0,0 +1,67 @@
+// Package grpcmw provides a small set of gRPC server interceptors for use with
+// ManageOpts.UnaryInterceptors / ManageOpts.StreamInterceptors. It has no client
+// interceptors for datasourcetest.TestPluginClient's client-side options.
+package grpcmw
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// LoggingUnaryInterceptor logs each unary RPC's method, peer, duration, status code
+// and error source once it completes.
+func LoggingUnaryInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(logger, ctx, info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor logs each streaming RPC's method, peer, duration and status
+// code once the stream ends.
+func LoggingStreamInterceptor(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(logger, ss.Context(), info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
+func logCall(logger log.Logger, ctx context.Context, method string, dur time.Duration, err error) {
+	args := []any{
+		"method", method,
+		"duration", dur,
+		"code", grpcstatus.Code(err).String(),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		args = append(args, "peer", p.Addr.String())
+	}
+
+	// Use the context-free variant here: this interceptor runs on the plugin's own
+	// server for a request it served, not on a host-side client ctx that
+	// ErrorSourceFromGrpcStatusError's WithErrorSource side effect expects, and calling
+	// that one would log a spurious "could not set ... error source" on every failed
+	// or panicking call.
+	if src, ok := backend.ErrorSourceFromGrpcStatus(err); ok {
+		args = append(args, "errorSource", src.String())
+	}
+
+	if err != nil && grpcstatus.Code(err) != codes.OK {
+		logger.Error("gRPC call failed", append(args, "error", err)...)
+		return
+	}
+
+	logger.Debug("gRPC call completed", args...)
+}
@@ -0,0 +1,82 @@
+package grpcmw
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Metrics holds the Prometheus collectors shared by the metrics interceptors. Register
+// it once against a prometheus.Registerer and pass it to both interceptors.
+type Metrics struct {
+	RPCTotal        *prometheus.CounterVec
+	RPCDuration     *prometheus.HistogramVec
+	StreamSentTotal *prometheus.CounterVec
+}
+
+// NewMetrics creates a Metrics registered against reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		RPCTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grafana_plugin_grpc_requests_total",
+			Help: "Total number of gRPC requests handled by the plugin, by method and code.",
+		}, []string{"method", "code"}),
+		RPCDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grafana_plugin_grpc_request_duration_seconds",
+			Help:    "Latency of gRPC requests handled by the plugin, by method and code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		StreamSentTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grafana_plugin_grpc_stream_messages_sent_total",
+			Help: "Total number of messages sent on gRPC server streams, by method.",
+		}, []string{"method"}),
+	}
+
+	reg.MustRegister(m.RPCTotal, m.RPCDuration, m.StreamSentTotal)
+	return m
+}
+
+// UnaryInterceptor records request counts and latency for unary RPCs.
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := grpcstatus.Code(err).String()
+		m.RPCTotal.WithLabelValues(info.FullMethod, code).Inc()
+		m.RPCDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
+
+// StreamInterceptor records request counts, latency and per-message send counts for
+// streaming RPCs such as QueryChunkedData.
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, &countingServerStream{ServerStream: ss, metrics: m, method: info.FullMethod})
+
+		code := grpcstatus.Code(err).String()
+		m.RPCTotal.WithLabelValues(info.FullMethod, code).Inc()
+		m.RPCDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// countingServerStream counts every message sent to the client, so the chunked-
+// streaming path (many Send calls per RPC) is visible in StreamSentTotal.
+type countingServerStream struct {
+	grpc.ServerStream
+	metrics *Metrics
+	method  string
+}
+
+func (s *countingServerStream) SendMsg(m any) error {
+	s.metrics.StreamSentTotal.WithLabelValues(s.method).Inc()
+	return s.ServerStream.SendMsg(m)
+}
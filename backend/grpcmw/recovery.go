@@ -0,0 +1,43 @@
+package grpcmw
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// RecoveryUnaryInterceptor recovers panics raised by a unary handler, logs them via
+// logger, and returns them as a gRPC error instead of crashing the plugin process. It's
+// a thin wrapper around the same recovery used for QueryData and QueryChunkedData (see
+// backend.NewDataAdapter); add it to ManageOpts.UnaryInterceptors to get the same
+// protection for other handlers, such as CallResource and CheckHealth.
+func RecoveryUnaryInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("panic recovered in gRPC handler", "method", info.FullMethod, "panic", r)
+				err = backend.PanicToGrpcError(r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor recovers panics raised by a streaming handler and logs them
+// via logger before returning them as a gRPC error.
+func RecoveryStreamInterceptor(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("panic recovered in gRPC stream handler", "method", info.FullMethod, "panic", r)
+				err = backend.PanicToGrpcError(r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
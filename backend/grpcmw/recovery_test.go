@@ -0,0 +1,79 @@
+package grpcmw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/status"
+)
+
+func TestRecoveryUnaryInterceptor_RecoversPanic(t *testing.T) {
+	interceptor := RecoveryUnaryInterceptor(log.NewNullLogger())
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	}
+
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Data/QueryData"}, handler)
+	require.Nil(t, resp)
+	require.Error(t, err)
+
+	st := grpcstatus.Convert(err)
+	require.Equal(t, codes.Internal, st.Code())
+
+	src, ok := backend.ErrorSourceFromGrpcStatus(err)
+	require.True(t, ok)
+	require.Equal(t, status.SourcePlugin, src)
+}
+
+func TestRecoveryUnaryInterceptor_PassesThroughNormalResult(t *testing.T) {
+	interceptor := RecoveryUnaryInterceptor(log.NewNullLogger())
+
+	wantResp := "ok"
+	handler := func(ctx context.Context, req any) (any, error) {
+		return wantResp, nil
+	}
+
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Data/QueryData"}, handler)
+	require.NoError(t, err)
+	require.Equal(t, wantResp, resp)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestRecoveryStreamInterceptor_RecoversPanic(t *testing.T) {
+	interceptor := RecoveryStreamInterceptor(log.NewNullLogger())
+
+	handler := func(srv any, ss grpc.ServerStream) error {
+		panic("boom")
+	}
+
+	err := interceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/Data/QueryChunkedData"}, handler)
+	require.Error(t, err)
+
+	st := grpcstatus.Convert(err)
+	require.Equal(t, codes.Internal, st.Code())
+}
+
+func TestRecoveryStreamInterceptor_PassesThroughNormalResult(t *testing.T) {
+	interceptor := RecoveryStreamInterceptor(log.NewNullLogger())
+
+	handler := func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}
+
+	err := interceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/Data/QueryChunkedData"}, handler)
+	require.NoError(t, err)
+}
@@ -0,0 +1,20 @@
+package grpcmw
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// contextServerStream overrides Context on a grpc.ServerStream so a stream interceptor
+// can hand a modified context (e.g. one carrying a span or a logger) down to the
+// handler, including through the chunked-streaming path where the handler only sees
+// grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse].
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
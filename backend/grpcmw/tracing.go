@@ -0,0 +1,43 @@
+package grpcmw
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// TracingUnaryInterceptor starts a span named after the RPC method around each unary
+// call and propagates it into the context passed to the handler (e.g. QueryData).
+func TracingUnaryInterceptor(tracer trace.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := tracer.Start(ctx, info.FullMethod)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		return resp, err
+	}
+}
+
+// TracingStreamInterceptor starts a span named after the RPC method around each
+// streaming call, e.g. QueryChunkedData, and propagates it into the context returned by
+// the wrapped stream's Context method, so the handler's ctx carries the span. Individual
+// Send calls, such as those ChunkedDataWriter makes, are not recorded on the span.
+func TracingStreamInterceptor(tracer trace.Tracer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := tracer.Start(ss.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ReattachConfigEnvVar carries a JSON-encoded ReattachConfig for a plugin server that is
+// already running, so a plugin host can attach to it instead of starting a new one. This
+// mirrors Terraform's TF_REATTACH_PROVIDERS and lets a data source be started under a
+// debugger (e.g. dlv exec) while Grafana, or a test driver, connects to it directly.
+//
+// It's defined here, rather than in experimental/datasourcetest, so that both
+// datasourcetest.Manage and a production plugin bootstrap can share one parsing
+// implementation instead of drifting apart.
+const ReattachConfigEnvVar = "GF_PLUGIN_REATTACH_CONFIG"
+
+// ReattachConfig describes an already-listening plugin gRPC server.
+type ReattachConfig struct {
+	Pid      int    `json:"pid"`
+	Addr     string `json:"addr"`
+	Network  string `json:"network"` // "tcp" or "unix"
+	Protocol string `json:"protocol"`
+}
+
+// ReattachConfigFromEnv reads and parses ReattachConfigEnvVar, returning a nil config
+// (and nil error) if it's unset.
+func ReattachConfigFromEnv() (*ReattachConfig, error) {
+	raw := os.Getenv(ReattachConfigEnvVar)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var cfg ReattachConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ReattachConfigEnvVar, err)
+	}
+	return &cfg, nil
+}
+
+// ListenForReattach opens the listener a plugin gRPC server should serve on: reattach's
+// network/address if set, otherwise "tcp" on defaultAddr. Centralizing this decision here,
+// rather than in each plugin host, is what's needed to wire GF_PLUGIN_REATTACH_CONFIG
+// support into a production bootstrap such as backend/datasource.Manage — that bootstrap
+// doesn't exist in this tree yet, so this is the piece of that work that can land now;
+// plumbing it into Serve's own listener setup is tracked as follow-up work in
+// backend/datasource.
+func ListenForReattach(reattach *ReattachConfig, defaultAddr string) (net.Listener, error) {
+	network, addr := "tcp", defaultAddr
+	if reattach != nil {
+		network, addr = reattach.Network, reattach.Addr
+	}
+
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return lis, nil
+}
@@ -16,7 +16,7 @@ type testDatasource struct {
 	rowsPerFrame int
 }
 
-func runDatasource(name string, ds testDatasource) {
+func runDatasource(name string, ds testDatasource, chunkedDataOpts backend.ChunkedDataOpts) {
 	logger.Info("Listening on", "addr", addr)
 
 	factory := datasource.InstanceFactoryFunc(func(_ context.Context, _ backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
@@ -26,6 +26,7 @@ func runDatasource(name string, ds testDatasource) {
 	p, err := experimentalDS.Manage(factory, experimentalDS.ManageOpts{
 		Address:           addr,
 		MaxReceiveMsgSize: 256 * 1024 * 1024, // 256MB
+		ChunkedDataOpts:   chunkedDataOpts,
 	})
 	panicIfErr(err)
 
@@ -39,14 +40,31 @@ func runDatasource(name string, ds testDatasource) {
 }
 
 func runDatasource1() {
+	// Many small frames (rowsPerFrame: 10): at the default MaxBatchRows of 1000 a
+	// flush carries only 100 frames, so each flush pays gRPC overhead for little
+	// payload. Raise MaxBatchRows to amortize that overhead across more frames,
+	// bounded by MaxBatchLatency so a slow trickle of queries still flushes promptly,
+	// and favor snappy's low per-call overhead over zstd's better ratio.
 	runDatasource("datasource1", testDatasource{
 		rowsPerFrame: 10,
+	}, backend.ChunkedDataOpts{
+		MaxBatchRows:    20_000,
+		MaxBatchLatency: 250 * time.Millisecond,
+		Compression:     backend.ChunkedDataCompressionSnappy,
 	})
 }
 
 func runDatasource2() {
+	// Few frames, 100k rows each: MaxBatchRows flushes every 1000 rows, in the middle
+	// of a frame. MaxBatchBytes only counts frames that are complete, so it adds a cap
+	// on buffered bytes across finished frames but doesn't flush a frame that is still
+	// being filled. zstd is used for its better ratio on the larger payloads.
 	runDatasource("datasource2", testDatasource{
 		rowsPerFrame: 100_000,
+	}, backend.ChunkedDataOpts{
+		MaxBatchRows:  1000,
+		MaxBatchBytes: 4 * 1024 * 1024,
+		Compression:   backend.ChunkedDataCompressionZstd,
 	})
 }
 
@@ -80,13 +98,16 @@ func (p *testDatasource) QueryData(ctx context.Context, req *backend.QueryDataRe
 func (p *testDatasource) QueryChunkedData(ctx context.Context, req *backend.ChunkedDataRequest, w backend.ChunkedDataWriter) error {
 	logger.Info("queryChunkedData", "req", req)
 
-	query := func(ctx context.Context, pCtx backend.PluginContext, q backend.DataQuery) {
+	// query returns a non-nil error if the client disconnected or canceled the
+	// request mid-stream; the caller stops rather than crashing the plugin.
+	query := func(ctx context.Context, pCtx backend.PluginContext, q backend.DataQuery) error {
 		for i := range q.MaxDataPoints {
 			frame := newFrame(fmt.Sprintf("F:%d, R:%s", i, q.RefID), 0)
-			err := w.WriteFrame(q.RefID, frame)
-			panicIfErr(err)
+			if err := w.WriteFrame(q.RefID, frame); err != nil {
+				return err
+			}
 			for i := 0; i < p.rowsPerFrame; i++ {
-				err = w.WriteFrameRow(q.RefID,
+				err := w.WriteFrameRow(q.RefID,
 					float32(i),
 					float64(i),
 					"str",
@@ -101,14 +122,27 @@ func (p *testDatasource) QueryChunkedData(ctx context.Context, req *backend.Chun
 					time.UnixMilli(int64(i)),
 					false,
 				)
-				panicIfErr(err)
+				if err != nil {
+					return err
+				}
+			}
+			// Checkpoint after each frame, so the next flushed response carries the
+			// frame index as its Cursor. Neither runDatasource1 nor runDatasource2 sets
+			// ChunkedDataOpts.ResumeCache, so the cursors are not recorded and a
+			// client that reconnects gets q from the start.
+			if err := w.Checkpoint(q.RefID, []byte(fmt.Sprintf("%d", i))); err != nil {
+				return err
 			}
 		}
+		return nil
 	}
 
 	// Loop over queries and execute them individually.
 	for _, q := range req.Queries {
-		query(ctx, req.PluginContext, q)
+		if err := query(ctx, req.PluginContext, q); err != nil {
+			logger.Warn("queryChunkedData stopped early", "refID", q.RefID, "error", err)
+			return err
+		}
 	}
 
 	return w.Close()
@@ -2,8 +2,14 @@ package datasourcetest
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	mathrand "math/rand/v2"
+	"net"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"google.golang.org/grpc"
@@ -15,6 +21,15 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
 )
 
+// Exponential backoff parameters QueryChunkedData uses between retries of a chunked
+// query that failed with a transient gRPC error, the familiar gRPC client defaults.
+const (
+	resumeBackoffBase   = time.Second
+	resumeBackoffFactor = 1.6
+	resumeBackoffMax    = 120 * time.Second
+	resumeBackoffJitter = 0.2
+)
+
 type TestPluginClient struct {
 	DataClient        pluginv2.DataClient
 	DiagnosticsClient pluginv2.DiagnosticsClient
@@ -23,18 +38,87 @@ type TestPluginClient struct {
 	conn *grpc.ClientConn
 }
 
-func newTestPluginClient(addr string) (*TestPluginClient, error) {
-	c, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithMaxMsgSize(1024*1024*1024))
+// ClientOption customizes the gRPC dial options used by the TestPluginClient
+// constructors.
+type ClientOption func(*[]grpc.DialOption)
+
+// WithUnaryClientInterceptors chains interceptors around every unary RPC the client
+// makes (QueryData, CheckHealth). The interceptors in backend/grpcmw are server-side
+// only and can't be passed here.
+func WithUnaryClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOption {
+	return func(opts *[]grpc.DialOption) {
+		*opts = append(*opts, grpc.WithChainUnaryInterceptor(interceptors...))
+	}
+}
+
+// WithStreamClientInterceptors chains interceptors around every streaming RPC the
+// client makes (QueryChunkedData, CallResource).
+func WithStreamClientInterceptors(interceptors ...grpc.StreamClientInterceptor) ClientOption {
+	return func(opts *[]grpc.DialOption) {
+		*opts = append(*opts, grpc.WithChainStreamInterceptor(interceptors...))
+	}
+}
+
+// WithTransportCompression makes every call use gRPC's transport-level compression
+// (e.g. gzip.Name) instead of, or in addition to, ChunkedDataOpts.Compression's
+// payload-level compression.
+func WithTransportCompression(name string) ClientOption {
+	return func(opts *[]grpc.DialOption) {
+		*opts = append(*opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(name)))
+	}
+}
+
+func dialOptions(opts []ClientOption) []grpc.DialOption {
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithMaxMsgSize(1024 * 1024 * 1024),
+	}
+	for _, opt := range opts {
+		opt(&dialOpts)
+	}
+	return dialOpts
+}
+
+func newTestPluginClient(addr string, opts ...ClientOption) (*TestPluginClient, error) {
+	c, err := grpc.NewClient(addr, dialOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTestPluginClientFromConn(c), nil
+}
+
+// NewTestPluginClientForReattach dials the plugin server described by cfg directly,
+// skipping the usual go-plugin handshake. Use it together with Manage's
+// ManageOpts.Reattach to attach to a plugin already running under a debugger.
+func NewTestPluginClientForReattach(cfg *ReattachConfig, opts ...ClientOption) (*TestPluginClient, error) {
+	target := cfg.Addr
+	if cfg.Network == "unix" {
+		target = "unix://" + cfg.Addr
+	}
+	return newTestPluginClient(target, opts...)
+}
+
+// NewTestPluginClientWithDialer dials a plugin server using dialer instead of a network
+// address, e.g. a bufconn.Listener's dialer, so a test can host the plugin's gRPC
+// server in the same process as the test itself.
+func NewTestPluginClientWithDialer(dialer func(context.Context, string) (net.Conn, error), opts ...ClientOption) (*TestPluginClient, error) {
+	dialOpts := append(dialOptions(opts), grpc.WithContextDialer(dialer))
+	c, err := grpc.NewClient("passthrough:///bufconn", dialOpts...)
 	if err != nil {
 		return nil, err
 	}
 
+	return newTestPluginClientFromConn(c), nil
+}
+
+func newTestPluginClientFromConn(c *grpc.ClientConn) *TestPluginClient {
 	return &TestPluginClient{
 		conn:              c,
 		DiagnosticsClient: pluginv2.NewDiagnosticsClient(c),
 		DataClient:        pluginv2.NewDataClient(c),
 		ResourceClient:    pluginv2.NewResourceClient(c),
-	}, nil
+	}
 }
 
 func (p *TestPluginClient) QueryData(ctx context.Context, r *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
@@ -48,44 +132,106 @@ func (p *TestPluginClient) QueryData(ctx context.Context, r *backend.QueryDataRe
 	return backend.FromProto().QueryDataResponse(resp)
 }
 
-func (p *TestPluginClient) QueryChunkedData(ctx context.Context, r *backend.ChunkedDataRequest) (*backend.QueryDataResponse, error) {
-	req := backend.ToProto().ChunkedDataRequest(r)
+// chunkedStreamState accumulates one refID's frames across a QueryChunkedData call,
+// including any reconnects: it's built once, outside the retry loop below, so a resumed
+// stream keeps merging into the same curFrame its predecessor was building.
+type chunkedStreamState struct {
+	frames       []*data.Frame
+	curFrame     *data.Frame
+	cursor       []byte // last Cursor seen for this refID, sent back as ResumeFrom on retry
+	lastSequence uint64 // last ChunkedDataResponse.Sequence seen for this refID
+}
 
-	stream, err := p.DataClient.QueryChunkedData(ctx, req)
+func (p *TestPluginClient) QueryChunkedData(ctx context.Context, r *backend.ChunkedDataRequest) (*backend.QueryDataResponse, error) {
+	resumeToken, err := newResumeToken()
 	if err != nil {
 		return nil, err
 	}
 
-	type streamState struct {
-		frames   []*data.Frame
-		curFrame *data.Frame
-	}
-
-	stateByRefID := make(map[string]streamState)
+	stateByRefID := make(map[string]*chunkedStreamState)
+	resumeFrom := make(map[string][]byte)
+	backoff := resumeBackoffBase
 
 	for {
-		sr, err := stream.Recv()
+		req := backend.ToProto().ChunkedDataRequest(r)
+		req.ResumeToken = resumeToken
+		req.ResumeFrom = resumeFrom
+
+		stream, err := p.DataClient.QueryChunkedData(ctx, req)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
+			return nil, err
+		}
 
-				resp := backend.Responses{}
-				for refID, state := range stateByRefID {
-					resp[refID] = backend.DataResponse{
-						Frames: state.frames,
-					}
-				}
+		streamErr := recvChunkedDataStream(stream, stateByRefID)
+		if errors.Is(streamErr, io.EOF) {
+			// End of stream: the query completed normally.
+			return partialChunkedDataResponse(stateByRefID), nil
+		}
 
-				// End of stream, return accumulated responses
-				return &backend.QueryDataResponse{Responses: resp}, nil
+		if status.Code(streamErr) == codes.Unavailable ||
+			status.Code(streamErr) == codes.ResourceExhausted ||
+			status.Code(streamErr) == codes.Aborted {
+			for refID, st := range stateByRefID {
+				resumeFrom[refID] = st.cursor
 			}
-			return nil, err
+
+			if sleepErr := sleepWithContext(ctx, jitter(backoff)); sleepErr != nil {
+				return partialChunkedDataResponse(stateByRefID), fmt.Errorf("chunked data stream ended early: %w", streamErr)
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		// Not a transient error, e.g. the client canceled ctx or the plugin crashed in
+		// a way status.Code can't tell apart from a permanent failure. Return whatever
+		// was received so far alongside the error instead of discarding it, so a
+		// caller that only cares about a best-effort partial result isn't forced to
+		// re-run the query.
+		return partialChunkedDataResponse(stateByRefID), fmt.Errorf("chunked data stream ended early: %w", streamErr)
+	}
+}
+
+// recvChunkedDataStream reads stream until it ends, merging frames into stateByRefID. It
+// returns the error Recv ended on, which is io.EOF for a stream that completed normally.
+func recvChunkedDataStream(stream pluginv2.Data_QueryChunkedDataClient, stateByRefID map[string]*chunkedStreamState) error {
+	for {
+		sr, err := stream.Recv()
+		if err != nil {
+			return err
 		}
 
 		st := stateByRefID[sr.RefId]
+		if st == nil {
+			st = &chunkedStreamState{}
+			stateByRefID[sr.RefId] = st
+		}
+
+		// A Sequence that doesn't advance past what we've already seen means the
+		// plugin restarted this refID from scratch instead of resuming it — its
+		// ResumeCache is disabled, or this refID's cursor aged out of the cache — so
+		// whatever we'd accumulated for it is stale and must be dropped before
+		// merging this response's frames, or they'd be appended on top of data
+		// that's about to be resent from the start.
+		if sr.Sequence != 0 && sr.Sequence <= st.lastSequence {
+			st.frames = nil
+			st.curFrame = nil
+			st.cursor = nil
+		}
+		st.lastSequence = sr.Sequence
+
+		if len(sr.Cursor) > 0 {
+			st.cursor = sr.Cursor
+		}
+
 		for _, frame := range sr.Frames {
-			f, err := data.UnmarshalArrowFrame(frame)
+			decoded, err := backend.DecompressChunkedDataFrame(backend.ChunkedDataCompression(sr.Compression), frame)
 			if err != nil {
-				return nil, err
+				return err
+			}
+
+			f, err := data.UnmarshalArrowFrame(decoded)
+			if err != nil {
+				return err
 			}
 
 			if f.Rows() == 0 {
@@ -105,8 +251,57 @@ func (p *TestPluginClient) QueryChunkedData(ctx context.Context, r *backend.Chun
 			st.frames = append(st.frames, f)
 			st.curFrame = f
 		}
+	}
+}
+
+func partialChunkedDataResponse(stateByRefID map[string]*chunkedStreamState) *backend.QueryDataResponse {
+	resp := backend.Responses{}
+	for refID, st := range stateByRefID {
+		resp[refID] = backend.DataResponse{
+			Frames: st.frames,
+		}
+	}
+	return &backend.QueryDataResponse{Responses: resp}
+}
+
+// newResumeToken generates the ResumeToken QueryChunkedData sends with every attempt of
+// a single logical query, so the plugin's ResumeCache can recognize a retry as a
+// continuation of the same query rather than an unrelated one.
+func newResumeToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate resume token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// nextBackoff advances delay by resumeBackoffFactor, capped at resumeBackoffMax.
+func nextBackoff(delay time.Duration) time.Duration {
+	delay = time.Duration(float64(delay) * resumeBackoffFactor)
+	if delay > resumeBackoffMax {
+		delay = resumeBackoffMax
+	}
+	return delay
+}
+
+// jitter randomizes delay by +/- resumeBackoffJitter so many clients retrying at once
+// don't all reconnect in lockstep.
+func jitter(delay time.Duration) time.Duration {
+	spread := 1 + resumeBackoffJitter*(2*mathrand.Float64()-1)
+	return time.Duration(float64(delay) * spread)
+}
 
-		stateByRefID[sr.RefId] = st
+// sleepWithContext waits for delay, returning early with ctx.Err() if ctx is canceled
+// first.
+func sleepWithContext(ctx context.Context, delay time.Duration) error {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
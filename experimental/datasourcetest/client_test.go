@@ -0,0 +1,172 @@
+package datasourcetest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+// fakeChunkedDataClientStream is a minimal pluginv2.Data_QueryChunkedDataClient that
+// replays a fixed sequence of responses, then ends with endErr, so tests can drive
+// recvChunkedDataStream without a real gRPC connection.
+type fakeChunkedDataClientStream struct {
+	pluginv2.Data_QueryChunkedDataClient
+
+	responses []*pluginv2.ChunkedDataResponse
+	endErr    error
+}
+
+func (f *fakeChunkedDataClientStream) Recv() (*pluginv2.ChunkedDataResponse, error) {
+	if len(f.responses) == 0 {
+		return nil, f.endErr
+	}
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, nil
+}
+
+func (f *fakeChunkedDataClientStream) Context() context.Context     { return context.Background() }
+func (f *fakeChunkedDataClientStream) Header() (metadata.MD, error) { return nil, nil }
+func (f *fakeChunkedDataClientStream) Trailer() metadata.MD         { return nil }
+func (f *fakeChunkedDataClientStream) CloseSend() error             { return nil }
+func (f *fakeChunkedDataClientStream) SendMsg(m any) error          { return nil }
+func (f *fakeChunkedDataClientStream) RecvMsg(m any) error          { return nil }
+
+func encodedFrame(t *testing.T, refID string, rows int) []byte {
+	t.Helper()
+	f := data.NewFrame("", data.NewField("v", nil, []int64{}))
+	for i := 0; i < rows; i++ {
+		f.AppendRow(int64(i))
+	}
+	f.RefID = refID
+
+	encoded, err := f.MarshalArrow()
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestRecvChunkedDataStream_MergesFramesAcrossResponses(t *testing.T) {
+	stream := &fakeChunkedDataClientStream{
+		responses: []*pluginv2.ChunkedDataResponse{
+			{RefId: "A", Sequence: 1, Cursor: []byte("c1"), Frames: [][]byte{encodedFrame(t, "A", 2)}},
+			{RefId: "A", Sequence: 2, Cursor: []byte("c2"), Frames: [][]byte{encodedFrame(t, "A", 3)}},
+		},
+		endErr: io.EOF,
+	}
+
+	stateByRefID := map[string]*chunkedStreamState{}
+	err := recvChunkedDataStream(stream, stateByRefID)
+	require.ErrorIs(t, err, io.EOF)
+
+	st := stateByRefID["A"]
+	require.NotNil(t, st)
+	require.Equal(t, 5, st.curFrame.Rows())
+	require.Equal(t, []byte("c2"), st.cursor)
+	require.Equal(t, uint64(2), st.lastSequence)
+}
+
+func TestRecvChunkedDataStream_ResetsStateWhenRefIDRestarts(t *testing.T) {
+	stateByRefID := map[string]*chunkedStreamState{}
+
+	// First connection: refID A gets to Sequence 5 before the stream drops.
+	first := &fakeChunkedDataClientStream{
+		responses: []*pluginv2.ChunkedDataResponse{
+			{RefId: "A", Sequence: 5, Cursor: []byte("c5"), Frames: [][]byte{encodedFrame(t, "A", 2)}},
+		},
+		endErr: context.DeadlineExceeded,
+	}
+	err := recvChunkedDataStream(first, stateByRefID)
+	require.Error(t, err)
+	require.Equal(t, 2, stateByRefID["A"].curFrame.Rows())
+
+	// Reconnect: the plugin couldn't resume A (e.g. ResumeCache disabled) and restarts
+	// it from scratch, so its Sequence starts back at 1 instead of continuing past 5.
+	second := &fakeChunkedDataClientStream{
+		responses: []*pluginv2.ChunkedDataResponse{
+			{RefId: "A", Sequence: 1, Frames: [][]byte{encodedFrame(t, "A", 1)}},
+		},
+		endErr: io.EOF,
+	}
+	err = recvChunkedDataStream(second, stateByRefID)
+	require.ErrorIs(t, err, io.EOF)
+
+	st := stateByRefID["A"]
+	require.Equal(t, 1, st.curFrame.Rows(), "stale rows from the dropped connection must not survive a restart")
+	require.Equal(t, uint64(1), st.lastSequence)
+}
+
+func TestRecvChunkedDataStream_KeepsStateAcrossASuccessfulResume(t *testing.T) {
+	stateByRefID := map[string]*chunkedStreamState{}
+
+	first := &fakeChunkedDataClientStream{
+		responses: []*pluginv2.ChunkedDataResponse{
+			{RefId: "A", Sequence: 3, Cursor: []byte("c3"), Frames: [][]byte{encodedFrame(t, "A", 2)}},
+		},
+		endErr: context.DeadlineExceeded,
+	}
+	require.Error(t, recvChunkedDataStream(first, stateByRefID))
+
+	// Reconnect: the plugin resumed A, so its cache-seeded Sequence continues past 3.
+	second := &fakeChunkedDataClientStream{
+		responses: []*pluginv2.ChunkedDataResponse{
+			{RefId: "A", Sequence: 4, Frames: [][]byte{encodedFrame(t, "A", 1)}},
+		},
+		endErr: io.EOF,
+	}
+	require.ErrorIs(t, recvChunkedDataStream(second, stateByRefID), io.EOF)
+
+	require.Equal(t, 3, stateByRefID["A"].curFrame.Rows())
+}
+
+func TestNextBackoff_CapsAtMax(t *testing.T) {
+	delay := resumeBackoffBase
+	for i := 0; i < 100; i++ {
+		delay = nextBackoff(delay)
+	}
+	require.LessOrEqual(t, delay, resumeBackoffMax)
+}
+
+func TestJitter_StaysWithinSpread(t *testing.T) {
+	base := 10 * time.Second
+	maxDelta := resumeBackoffJitter * float64(base)
+	for i := 0; i < 50; i++ {
+		got := jitter(base)
+		require.InDelta(t, float64(base), float64(got), maxDelta+1)
+	}
+}
+
+func TestSleepWithContext_ReturnsEarlyOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := sleepWithContext(ctx, time.Minute)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewResumeToken_IsNonEmptyAndUnique(t *testing.T) {
+	a, err := newResumeToken()
+	require.NoError(t, err)
+	require.NotEmpty(t, a)
+
+	b, err := newResumeToken()
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+}
+
+func TestPartialChunkedDataResponse(t *testing.T) {
+	f := data.NewFrame("A")
+	stateByRefID := map[string]*chunkedStreamState{
+		"A": {frames: []*data.Frame{f}},
+	}
+
+	resp := partialChunkedDataResponse(stateByRefID)
+	require.Contains(t, resp.Responses, "A")
+	require.Equal(t, data.Frames{f}, resp.Responses["A"].Frames)
+}
@@ -0,0 +1,202 @@
+package datasourcetest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/grpcmw"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/genproto/pluginv2"
+)
+
+// ReattachConfig describes an already-listening plugin gRPC server. It's an alias for
+// backend.ReattachConfig, which owns the type and its GF_PLUGIN_REATTACH_CONFIG parsing,
+// so this harness and a production plugin bootstrap share one implementation instead of
+// drifting apart.
+type ReattachConfig = backend.ReattachConfig
+
+// ManageOpts customizes the behavior of Manage.
+type ManageOpts struct {
+	// Address is the address the gRPC server listens on when Reattach and Listener
+	// are both unset.
+	Address string
+
+	// MaxReceiveMsgSize sets the gRPC server's maximum receive message size, in bytes.
+	MaxReceiveMsgSize int
+
+	// Reattach, when set, makes Manage listen on the given address/network instead of
+	// Address, and skips any plugin handshake. When unset, Manage also checks the
+	// GF_PLUGIN_REATTACH_CONFIG environment variable.
+	Reattach *ReattachConfig
+
+	// Listener, when set, is used instead of dialing Address or Reattach.Addr. It's
+	// primarily useful for tests that want to host the plugin in-process, e.g. on a
+	// bufconn.Listener.
+	Listener net.Listener
+
+	// UnaryInterceptors and StreamInterceptors are chained, in order, around every
+	// unary and streaming RPC the plugin serves. See backend/grpcmw for a set of
+	// ready-made interceptors (logging, tracing, metrics, recovery, context tags).
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+
+	// DisableRecovery disables the panic recovery Manage installs by default, so a
+	// handler panic crashes the test process instead of becoming a codes.Internal
+	// error. Useful when a test wants to assert on the panic itself.
+	DisableRecovery bool
+
+	// ChunkedDataOpts configures batching and compression for QueryChunkedData. See
+	// backend.ChunkedDataOpts.
+	ChunkedDataOpts backend.ChunkedDataOpts
+}
+
+// Plugin is a running instance of a data source plugin's gRPC server.
+type Plugin struct {
+	server   *grpc.Server
+	listener net.Listener
+	reattach *ReattachConfig
+}
+
+// Manage starts a gRPC server hosting the data source built from factory and serves it
+// until Shutdown is called. Unlike backend/datasource.Manage, it never performs the
+// go-plugin handshake, which makes it suitable for debugging a plugin under a debugger
+// or hosting it alongside a test driver in the same process.
+//
+// This only covers running the data source under this package's own harness, not under
+// a real backend/datasource.Manage/backend.Serve plugin process attached to Grafana. The
+// GF_PLUGIN_REATTACH_CONFIG parsing and reattach-vs-fresh-listener decision it relies on
+// now live in backend.ReattachConfigFromEnv and backend.ListenForReattach, shared with
+// that production bootstrap, so wiring reattach into backend/datasource.Manage only needs
+// to call those two functions from Serve's own listener setup; that bootstrap itself
+// doesn't exist in this module yet, so the actual call site is still follow-up work.
+func Manage(factory datasource.InstanceFactoryFunc, opts ManageOpts) (*Plugin, error) {
+	reattach := opts.Reattach
+	if reattach == nil {
+		cfg, err := backend.ReattachConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		reattach = cfg
+	}
+
+	lis := opts.Listener
+	if lis == nil {
+		var err error
+		lis, err = backend.ListenForReattach(reattach, opts.Address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	unaryInterceptors := opts.UnaryInterceptors
+	streamInterceptors := opts.StreamInterceptors
+	if !opts.DisableRecovery {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{grpcmw.RecoveryUnaryInterceptor(backend.Logger)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{grpcmw.RecoveryStreamInterceptor(backend.Logger)}, streamInterceptors...)
+	}
+
+	var serverOpts []grpc.ServerOption
+	if opts.MaxReceiveMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(opts.MaxReceiveMsgSize))
+	}
+	if len(unaryInterceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(unaryInterceptors...))
+	}
+	if len(streamInterceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.ChainStreamInterceptor(streamInterceptors...))
+	}
+
+	server := grpc.NewServer(serverOpts...)
+	adapter := &testServerAdapter{
+		im:              instancemgmt.New(factory),
+		disableRecovery: opts.DisableRecovery,
+		chunkedDataOpts: opts.ChunkedDataOpts,
+	}
+
+	pluginv2.RegisterDataServer(server, adapter)
+	pluginv2.RegisterDiagnosticsServer(server, adapter)
+	pluginv2.RegisterResourceServer(server, adapter)
+
+	p := &Plugin{server: server, listener: lis, reattach: reattach}
+
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	return p, nil
+}
+
+// Reattach returns the ReattachConfig a client can use to dial this plugin, deriving
+// Addr from the listener actually bound if the caller didn't pin one (e.g. ":0").
+func (p *Plugin) Reattach() (*ReattachConfig, error) {
+	cfg := &ReattachConfig{
+		Pid:      os.Getpid(),
+		Network:  p.listener.Addr().Network(),
+		Addr:     p.listener.Addr().String(),
+		Protocol: "grpc",
+	}
+	if p.reattach != nil {
+		cfg.Protocol = p.reattach.Protocol
+	}
+	return cfg, nil
+}
+
+// Shutdown stops the plugin's gRPC server.
+func (p *Plugin) Shutdown() error {
+	p.server.GracefulStop()
+	return nil
+}
+
+// testServerAdapter resolves a plugin instance for each request and forwards it to
+// backend.NewDataAdapter, the same adapter backend.Serve's production bootstrap uses, so
+// tests written against this harness observe the same error-source enrichment and panic
+// recovery semantics a real plugin host provides.
+type testServerAdapter struct {
+	pluginv2.UnimplementedDiagnosticsServer
+	pluginv2.UnimplementedResourceServer
+
+	im              instancemgmt.InstanceManager
+	disableRecovery bool
+	chunkedDataOpts backend.ChunkedDataOpts
+}
+
+func (a *testServerAdapter) QueryData(ctx context.Context, req *pluginv2.QueryDataRequest) (*pluginv2.QueryDataResponse, error) {
+	parsedReq := backend.FromProto().QueryDataRequest(req)
+
+	inst, err := a.im.Get(ctx, parsedReq.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	handler, ok := inst.(backend.QueryDataHandler)
+	if !ok {
+		return nil, fmt.Errorf("instance does not implement QueryDataHandler")
+	}
+
+	adapter := backend.NewDataAdapter(handler, nil, a.disableRecovery, backend.ChunkedDataOpts{})
+	return adapter.QueryData(ctx, req)
+}
+
+func (a *testServerAdapter) QueryChunkedData(req *pluginv2.ChunkedDataRequest, stream grpc.ServerStreamingServer[pluginv2.ChunkedDataResponse]) error {
+	ctx := stream.Context()
+	parsedReq := backend.FromProto().ChunkedDataRequest(req)
+
+	inst, err := a.im.Get(ctx, parsedReq.PluginContext)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := inst.(backend.ChunkedDataHandler)
+	if !ok {
+		return fmt.Errorf("instance does not implement ChunkedDataHandler")
+	}
+
+	adapter := backend.NewDataAdapter(nil, handler, a.disableRecovery, a.chunkedDataOpts)
+	return adapter.QueryChunkedData(req, stream)
+}